@@ -1,14 +1,80 @@
 package gaper
 
+// This package depends on github.com/fsnotify/fsnotify and
+// github.com/sabhiram/go-gitignore in addition to the pre-existing
+// github.com/mattn/go-zglob; both must be present in go.mod/go.sum
+// alongside it.
 import (
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	zglob "github.com/mattn/go-zglob"
+	gitignore "github.com/sabhiram/go-gitignore"
+)
+
+// gaperIgnoreFile is the name of the optional, gitignore-style ignore file
+// auto-loaded from the working directory
+const gaperIgnoreFile = ".gaperignore"
+
+// Op describes the kind of change a typed Event represents
+type Op int
+
+const (
+	// Create indicates a path wasn't previously known to the watcher
+	Create Op = iota
+	// Write indicates the contents of an already-known path changed
+	Write
+	// Remove indicates a path was deleted
+	Remove
+	// Rename indicates a path was moved or renamed
+	Rename
+	// Chmod indicates only the permissions/metadata of a path changed
+	Chmod
+)
+
+func (op Op) String() string {
+	switch op {
+	case Create:
+		return "CREATE"
+	case Write:
+		return "WRITE"
+	case Remove:
+		return "REMOVE"
+	case Rename:
+		return "RENAME"
+	case Chmod:
+		return "CHMOD"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Event carries a changed path along with what kind of change it was and
+// when it was observed
+type Event struct {
+	Path    string
+	Op      Op
+	ModTime time.Time
+}
+
+// WatcherBackend selects the strategy used to detect file changes
+type WatcherBackend int
+
+const (
+	// BackendAuto picks fsnotify when available, falling back to polling
+	// on platforms/filesystems where it can't be used (e.g. ENOSPC)
+	BackendAuto WatcherBackend = iota
+	// BackendPoll walks the watched trees on a fixed interval
+	BackendPoll
+	// BackendFSNotify subscribes to OS-level filesystem events
+	BackendFSNotify
 )
 
 // Watcher is a interface for the watch process
@@ -16,8 +82,14 @@ type Watcher interface {
 	Watch()
 	Errors() chan error
 	Events() chan string
+	TypedEvents() <-chan Event
+	BatchEvents() chan []string
+	ScanNow() error
 }
 
+// ErrWatcherNotRunning is returned by ScanNow when Watch hasn't been started
+var ErrWatcherNotRunning = errors.New("watcher is not running")
+
 // watcher is a interface for the watch process
 type watcher struct {
 	pollInterval      int
@@ -25,15 +97,70 @@ type watcher struct {
 	ignoreItems       map[string]bool
 	allowedExtensions map[string]bool
 	events            chan string
+	typedEvents       chan Event
 	errors            chan error
+
+	backend       WatcherBackend
+	fsWatcher     *fsnotify.Watcher
+	ignoreMatcher *gitignore.GitIgnore
+	modTimes      map[string]time.Time
+
+	// watchDirs holds every directory registered with fsWatcher because a
+	// watch item resolved to that directory (or a subdirectory discovered
+	// under it); watchFiles holds individual files whose parent directory
+	// was registered only to be able to see changes to that one file.
+	// Together they let acceptFSEvent tell a sibling file in a
+	// file-only-watched directory apart from a file in a fully watched one.
+	watchDirs  map[string]bool
+	watchFiles map[string]bool
+
+	debounceInterval time.Duration
+	maxEvents        int
+	rawEvents        chan Event
+	batchEvents      chan []string
+
+	mu        sync.Mutex
+	startTime time.Time
+	running   bool
+}
+
+// WatcherConfig holds the parameters for NewWatcher. Zero values fall back
+// to sane defaults: PollInterval to DefaultPoolInterval, Extensions to
+// DefaultExtensions, and Backend to BackendAuto.
+//
+// DebounceInterval, when greater than zero, buffers paths detected during
+// that window and emits them as a single batch once the window elapses
+// instead of one event per change. MaxEvents caps how many distinct paths
+// are buffered before a batch is flushed early, regardless of the
+// debounce window; zero means unbounded. The poll backend detects at most
+// a single change per watch path per poll tick, so with a DebounceInterval
+// shorter than PollInterval a batch only coalesces the changes found under
+// different watch paths during the same tick.
+//
+// Once DebounceInterval is set, Events(), TypedEvents() and BatchEvents()
+// are all best-effort: a caller only needs to read the channel(s) it
+// actually cares about, and a send on one that nobody is reading is
+// dropped rather than blocking the others. With DebounceInterval unset,
+// Events() keeps its original, pre-debounce contract of a blocking send
+// that must always have a reader.
+type WatcherConfig struct {
+	PollInterval     int
+	WatchItems       []string
+	IgnoreItems      []string
+	Extensions       []string
+	Backend          WatcherBackend
+	DebounceInterval time.Duration
+	MaxEvents        int
 }
 
 // NewWatcher creates a new watcher
-func NewWatcher(pollInterval int, watchItems []string, ignoreItems []string, extensions []string) (Watcher, error) {
+func NewWatcher(config WatcherConfig) (Watcher, error) {
+	pollInterval := config.PollInterval
 	if pollInterval == 0 {
 		pollInterval = DefaultPoolInterval
 	}
 
+	extensions := config.Extensions
 	if len(extensions) == 0 {
 		extensions = DefaultExtensions
 	}
@@ -43,44 +170,240 @@ func NewWatcher(pollInterval int, watchItems []string, ignoreItems []string, ext
 		allowedExts["."+ext] = true
 	}
 
-	watchPaths, err := resolvePaths(watchItems, allowedExts)
+	watchPaths, err := resolvePaths(config.WatchItems, allowedExts)
 	if err != nil {
 		return nil, err
 	}
 
-	ignorePaths, err := resolvePaths(ignoreItems, allowedExts)
+	ignorePaths, ignoreMatcher, err := resolveIgnores(config.IgnoreItems)
 	if err != nil {
 		return nil, err
 	}
 
 	logger.Debugf("Resolved watch paths: %v", watchPaths)
 	logger.Debugf("Resolved ignore paths: %v", ignorePaths)
-	return &watcher{
+
+	w := &watcher{
 		events:            make(chan string),
+		typedEvents:       make(chan Event),
 		errors:            make(chan error),
+		batchEvents:       make(chan []string),
 		pollInterval:      pollInterval,
 		watchItems:        watchPaths,
 		ignoreItems:       ignorePaths,
 		allowedExtensions: allowedExts,
-	}, nil
+		backend:           config.Backend,
+		debounceInterval:  config.DebounceInterval,
+		maxEvents:         config.MaxEvents,
+		ignoreMatcher:     ignoreMatcher,
+		modTimes:          make(map[string]time.Time),
+		watchDirs:         make(map[string]bool),
+		watchFiles:        make(map[string]bool),
+		startTime:         time.Now(),
+	}
+
+	if w.debounceInterval > 0 {
+		w.rawEvents = make(chan Event)
+	}
+
+	if w.backend == BackendFSNotify || w.backend == BackendAuto {
+		fsWatcher, err := w.newFSNotifyWatcher()
+		if err != nil {
+			if w.backend == BackendFSNotify {
+				return nil, err
+			}
+			logger.Debugf("Falling back to poll backend: %v", err)
+			w.backend = BackendPoll
+		} else {
+			w.backend = BackendFSNotify
+			w.fsWatcher = fsWatcher
+		}
+	}
+
+	return w, nil
 }
 
-var startTime = time.Now()
 var errDetectedChange = errors.New("done")
 
 // Watch starts watching for file changes
 func (w *watcher) Watch() {
+	w.mu.Lock()
+	w.running = true
+	w.mu.Unlock()
+
+	defer func() {
+		w.mu.Lock()
+		w.running = false
+		w.mu.Unlock()
+	}()
+
+	if w.debounceInterval > 0 {
+		go w.debounceLoop()
+	}
+
+	if w.backend == BackendFSNotify {
+		w.watchFSNotify()
+		return
+	}
+
+	w.watchPoll()
+}
+
+// ScanNow performs one synchronous pass over all watched paths, emitting
+// any discovered changes on the normal Events()/TypedEvents() channels. It
+// returns ErrWatcherNotRunning if Watch hasn't been started, and is safe to
+// call concurrently with the running poll loop.
+func (w *watcher) ScanNow() error {
+	w.mu.Lock()
+	running := w.running
+	w.mu.Unlock()
+
+	if !running {
+		return ErrWatcherNotRunning
+	}
+
+	for watchPath := range w.watchItems {
+		changed, err := w.scanChange(watchPath)
+		if err != nil {
+			return err
+		}
+
+		if changed.Path != "" {
+			w.emit(changed)
+		}
+	}
+
+	return nil
+}
+
+// touchStartTime advances the reference time used to detect changes,
+// guarded so it can be read and written safely alongside a concurrent
+// scanChange call. It has no effect for the poll path, which advances
+// startTime itself while still holding the lock; it's used by the
+// fsnotify backend, which doesn't otherwise touch startTime.
+func (w *watcher) touchStartTime() {
+	w.mu.Lock()
+	w.startTime = time.Now()
+	w.mu.Unlock()
+}
+
+// emit sends a detected event to the typed and legacy events channels
+// directly, or, when debouncing is enabled, hands it to the debounce loop
+// to be coalesced. Without debouncing, Events() keeps its original
+// contract: the send blocks, so a caller using that channel must drain it.
+func (w *watcher) emit(ev Event) {
+	if w.debounceInterval <= 0 {
+		w.emitTyped(ev)
+		w.events <- ev.Path
+		return
+	}
+
+	w.rawEvents <- ev
+}
+
+// emitTyped forwards ev to typedEvents without blocking, since nothing
+// requires a caller to be reading TypedEvents()
+func (w *watcher) emitTyped(ev Event) {
+	select {
+	case w.typedEvents <- ev:
+	default:
+	}
+}
+
+// emitLegacy forwards path to events without blocking. Only flush() uses
+// this: once debouncing is enabled, a caller is expected to read whichever
+// of Events()/TypedEvents()/BatchEvents() it actually wants, and a blocking
+// send here would let an unread Events() channel back up rawEvents and
+// stall the poll/fsnotify loop behind it.
+func (w *watcher) emitLegacy(path string) {
+	select {
+	case w.events <- path:
+	default:
+	}
+}
+
+// emitBatch forwards batch to batchEvents without blocking, since nothing
+// requires a caller to be reading BatchEvents()
+func (w *watcher) emitBatch(batch []string) {
+	select {
+	case w.batchEvents <- batch:
+	default:
+	}
+}
+
+// debounceLoop buffers events received on rawEvents and flushes them as a
+// batch once debounceInterval elapses without a new change, or as soon as
+// maxEvents distinct paths have accumulated
+func (w *watcher) debounceLoop() {
+	var pendingOrder []string
+	pendingEvents := map[string]Event{}
+
+	var timer *time.Timer
+	var timerC <-chan time.Time
+
+	flush := func() {
+		if len(pendingOrder) == 0 {
+			return
+		}
+
+		batch := make([]string, len(pendingOrder))
+		copy(batch, pendingOrder)
+
+		w.emitTyped(pendingEvents[batch[len(batch)-1]])
+		w.emitLegacy(batch[len(batch)-1])
+		w.emitBatch(batch)
+
+		pendingOrder = nil
+		pendingEvents = map[string]Event{}
+	}
+
+	for {
+		select {
+		case ev, ok := <-w.rawEvents:
+			if !ok {
+				return
+			}
+
+			if _, seen := pendingEvents[ev.Path]; !seen {
+				pendingOrder = append(pendingOrder, ev.Path)
+			}
+			pendingEvents[ev.Path] = ev
+
+			if timer == nil {
+				timer = time.NewTimer(w.debounceInterval)
+			} else {
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(w.debounceInterval)
+			}
+			timerC = timer.C
+
+			if w.maxEvents > 0 && len(pendingOrder) >= w.maxEvents {
+				flush()
+				timer = nil
+				timerC = nil
+			}
+		case <-timerC:
+			flush()
+			timer = nil
+			timerC = nil
+		}
+	}
+}
+
+// watchPoll walks the watched trees on every tick of pollInterval
+func (w *watcher) watchPoll() {
 	for {
 		for watchPath := range w.watchItems {
-			fileChanged, err := w.scanChange(watchPath)
+			changed, err := w.scanChange(watchPath)
 			if err != nil {
 				w.errors <- err
 				return
 			}
 
-			if fileChanged != "" {
-				w.events <- fileChanged
-				startTime = time.Now()
+			if changed.Path != "" {
+				w.emit(changed)
 			}
 		}
 
@@ -88,21 +411,234 @@ func (w *watcher) Watch() {
 	}
 }
 
+// watchFSNotify relays fsnotify events for the watched trees, adding and
+// removing watches as subdirectories are created and removed
+func (w *watcher) watchFSNotify() {
+	for {
+		select {
+		case ev, ok := <-w.fsWatcher.Events:
+			if !ok {
+				return
+			}
+
+			// directory create/remove must be handled ahead of acceptFSEvent,
+			// which rejects directories outright (they never match the
+			// extension filter), or new subdirectories would never get
+			// watched and removed ones would leak their watch
+			if ev.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					// a directory created beside a file-only watch item isn't
+					// part of a watched tree, so it gets no watch of its own
+					if len(w.watchFiles) == 0 || w.watchDirs[filepath.Dir(ev.Name)] {
+						if err := w.addFSNotifyDirRecursive(w.fsWatcher, ev.Name); err != nil {
+							w.errors <- err
+						}
+					}
+					continue
+				}
+			}
+
+			if ev.Op&fsnotify.Remove != 0 {
+				// best effort: fsnotify drops watches on removed dirs on its own,
+				// but an explicit Remove avoids leaking watches on some platforms
+				_ = w.fsWatcher.Remove(ev.Name)
+				delete(w.watchDirs, ev.Name)
+				delete(w.watchFiles, ev.Name)
+			}
+
+			if !w.acceptFSEvent(ev.Name) {
+				continue
+			}
+
+			if ev.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename|fsnotify.Remove|fsnotify.Chmod) == 0 {
+				continue
+			}
+
+			w.emit(w.toEvent(ev))
+			w.touchStartTime()
+		case err, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return
+			}
+			w.errors <- err
+			return
+		}
+	}
+}
+
+// acceptFSEvent applies the extension and ignore-path filters to a raw
+// fsnotify event path
+func (w *watcher) acceptFSEvent(path string) bool {
+	if dir := filepath.Base(path); len(dir) > 0 && dir[0] == '.' && dir != "." {
+		return false
+	}
+
+	if w.isIgnored(path) {
+		return false
+	}
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return false
+	}
+
+	if len(w.watchFiles) > 0 && !w.watchFiles[path] && !w.watchDirs[filepath.Dir(path)] {
+		// this directory is only watched because it holds a file-only watch
+		// item, not because the directory itself was a watch target
+		return false
+	}
+
+	_, ok := w.allowedExtensions[filepath.Ext(path)]
+	return ok
+}
+
+// toEvent translates a raw fsnotify.Event into gaper's typed Event
+func (w *watcher) toEvent(ev fsnotify.Event) Event {
+	modTime := time.Now()
+	if info, err := os.Stat(ev.Name); err == nil {
+		modTime = info.ModTime()
+	}
+
+	var op Op
+	switch {
+	case ev.Op&fsnotify.Create != 0:
+		op = Create
+	case ev.Op&fsnotify.Remove != 0:
+		op = Remove
+	case ev.Op&fsnotify.Rename != 0:
+		op = Rename
+	case ev.Op&fsnotify.Chmod != 0:
+		op = Chmod
+	default:
+		op = Write
+	}
+
+	return Event{Path: ev.Name, Op: op, ModTime: modTime}
+}
+
+// isIgnored reports whether path matches a literal ignore entry or the
+// gitignore-style pattern matcher
+func (w *watcher) isIgnored(path string) bool {
+	if _, ignored := w.ignoreItems[path]; ignored {
+		return true
+	}
+
+	return w.ignoreMatcher != nil && w.ignoreMatcher.MatchesPath(path)
+}
+
+// newFSNotifyWatcher creates an fsnotify watcher and registers every
+// resolved watch path: directories are walked recursively, and a watch
+// item that resolves to an individual file is watched through its parent
+// directory, since fsnotify has no per-file watch primitive
+func (w *watcher) newFSNotifyWatcher() (*fsnotify.Watcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	for watchPath := range w.watchItems {
+		info, statErr := os.Stat(watchPath)
+		if statErr == nil && !info.IsDir() {
+			if err := w.addFSNotifyFileWatch(fsWatcher, watchPath); err != nil {
+				fsWatcher.Close()
+				return nil, err
+			}
+			continue
+		}
+
+		if err := w.addFSNotifyDirRecursive(fsWatcher, watchPath); err != nil {
+			fsWatcher.Close()
+			return nil, err
+		}
+	}
+
+	return fsWatcher, nil
+}
+
+// addFSNotifyFileWatch registers a watch on path's parent directory and
+// records path in watchFiles, so acceptFSEvent can filter out unrelated
+// siblings fsnotify will also report from that directory
+func (w *watcher) addFSNotifyFileWatch(fsWatcher *fsnotify.Watcher, path string) error {
+	w.watchFiles[path] = true
+
+	dir := filepath.Dir(path)
+	if err := fsWatcher.Add(dir); err != nil {
+		if errors.Is(err, syscall.ENOSPC) {
+			return err
+		}
+		logger.Debugf("Couldn't watch %s: %v", dir, err)
+	}
+
+	return nil
+}
+
+// addFSNotifyDirRecursive walks root and registers an fsnotify watch on
+// every directory found, skipping hidden and ignored directories
+func (w *watcher) addFSNotifyDirRecursive(fsWatcher *fsnotify.Watcher, root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		if dir := filepath.Base(path); dir[0] == '.' && dir != "." {
+			return filepath.SkipDir
+		}
+
+		if w.isIgnored(path) {
+			return filepath.SkipDir
+		}
+
+		if err := fsWatcher.Add(path); err != nil {
+			if errors.Is(err, syscall.ENOSPC) {
+				return err
+			}
+			logger.Debugf("Couldn't watch %s: %v", path, err)
+			return nil
+		}
+
+		w.watchDirs[path] = true
+		return nil
+	})
+}
+
 // Events get events occurred during the watching
 // these events are emited only a file changing is detected
 func (w *watcher) Events() chan string {
 	return w.events
 }
 
+// TypedEvents get typed events occurred during the watching, carrying the
+// operation kind (Create, Write, Remove, Rename, Chmod) and mod time
+// alongside the path
+func (w *watcher) TypedEvents() <-chan Event {
+	return w.typedEvents
+}
+
 // Errors get errors occurred during the watching
 func (w *watcher) Errors() chan error {
 	return w.errors
 }
 
-func (w *watcher) scanChange(watchPath string) (string, error) {
+// BatchEvents get the deduplicated set of paths collected during a single
+// debounce window; only populated when DebounceInterval is set
+func (w *watcher) BatchEvents() chan []string {
+	return w.batchEvents
+}
+
+// scanChange walks watchPath looking for a single changed file. The scan
+// holds w.mu for its entire duration, not just around the state it
+// touches, so a concurrent ScanNow can't interleave with the poll loop and
+// both observe the same stale startTime and emit the same change twice.
+func (w *watcher) scanChange(watchPath string) (Event, error) {
 	logger.Debug("Watching ", watchPath)
 
-	var fileChanged string
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var changed Event
 
 	err := filepath.Walk(watchPath, func(path string, info os.FileInfo, err error) error {
 		// always ignore hidden files and directories
@@ -110,24 +646,39 @@ func (w *watcher) scanChange(watchPath string) (string, error) {
 			return skipFile(info)
 		}
 
-		if _, ignored := w.ignoreItems[path]; ignored {
+		if w.isIgnored(path) {
 			return skipFile(info)
 		}
 
 		ext := filepath.Ext(path)
-		if _, ok := w.allowedExtensions[ext]; ok && info.ModTime().After(startTime) {
-			fileChanged = path
-			return errDetectedChange
+		if _, ok := w.allowedExtensions[ext]; !ok {
+			return nil
 		}
 
-		return nil
+		if !info.ModTime().After(w.startTime) {
+			return nil
+		}
+
+		op := Write
+		if _, seen := w.modTimes[path]; !seen {
+			op = Create
+		}
+		w.modTimes[path] = info.ModTime()
+
+		// advance startTime while still holding the lock so a concurrent
+		// scan can't see the same stale startTime and detect this same
+		// change again before this one is reflected
+		w.startTime = time.Now()
+
+		changed = Event{Path: path, Op: op, ModTime: info.ModTime()}
+		return errDetectedChange
 	})
 
 	if err != nil && err != errDetectedChange {
-		return "", err
+		return Event{}, err
 	}
 
-	return fileChanged, nil
+	return changed, nil
 }
 
 func resolvePaths(paths []string, extensions map[string]bool) (map[string]bool, error) {
@@ -165,6 +716,75 @@ func resolvePaths(paths []string, extensions map[string]bool) (map[string]bool,
 	return result, nil
 }
 
+// resolveIgnores splits ignoreItems into literal paths, resolved the same
+// way watchItems are, and gitignore-style patterns compiled into a single
+// matcher. Patterns found in a .gaperignore file in the working directory,
+// if present, are appended to the pattern set.
+func resolveIgnores(items []string) (map[string]bool, *gitignore.GitIgnore, error) {
+	literal, patterns := splitIgnoreEntries(items)
+
+	filePatterns, err := readGaperIgnoreFile(gaperIgnoreFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	patterns = append(patterns, filePatterns...)
+
+	literalPaths, err := resolvePaths(literal, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var matcher *gitignore.GitIgnore
+	if len(patterns) > 0 {
+		matcher = gitignore.CompileIgnoreLines(patterns...)
+	}
+
+	return literalPaths, matcher, nil
+}
+
+// splitIgnoreEntries separates literal paths from gitignore-style patterns
+// (globs, negations, or directory markers) found in ignoreItems
+func splitIgnoreEntries(items []string) (literal []string, patterns []string) {
+	for _, item := range items {
+		if isIgnorePattern(item) {
+			patterns = append(patterns, item)
+		} else {
+			literal = append(literal, item)
+		}
+	}
+
+	return literal, patterns
+}
+
+// isIgnorePattern reports whether an ignore entry should be treated as a
+// gitignore-style pattern rather than a literal, resolvable path
+func isIgnorePattern(item string) bool {
+	return strings.ContainsAny(item, "*?[!") || strings.HasSuffix(item, "/")
+}
+
+// readGaperIgnoreFile loads one pattern per line from a .gaperignore file,
+// skipping blank lines and comments. A missing file is not an error.
+func readGaperIgnoreFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+
+	return patterns, nil
+}
+
 // remove overlapped paths so it makes the scan for changes later faster and simpler
 func removeOverlappedPaths(mapPaths map[string]bool) {
 	for p1 := range mapPaths {
@@ -0,0 +1,388 @@
+package gaper
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestDebounceLoopDedupesBatchEvents asserts that multiple changes to the
+// same path within a single debounce window are coalesced into one entry
+// in the batch handed to BatchEvents(), not a raw, duplicate-laden list.
+func TestDebounceLoopDedupesBatchEvents(t *testing.T) {
+	w := &watcher{
+		rawEvents:        make(chan Event),
+		batchEvents:      make(chan []string),
+		typedEvents:      make(chan Event),
+		events:           make(chan string),
+		debounceInterval: 20 * time.Millisecond,
+	}
+
+	go w.debounceLoop()
+
+	// deliberately not draining typedEvents or events: flush() must not
+	// block on either of them, only batchEvents is read below
+	go func() {
+		w.rawEvents <- Event{Path: "a.go", Op: Write}
+		w.rawEvents <- Event{Path: "a.go", Op: Write}
+	}()
+
+	select {
+	case batch := <-w.batchEvents:
+		if len(batch) != 1 {
+			t.Fatalf("expected 1 deduped path, got %d: %v", len(batch), batch)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a batch")
+	}
+}
+
+// TestScanNowConcurrentWithWatch asserts that ScanNow refuses to run
+// before Watch starts, and that calling it concurrently with the running
+// poll loop doesn't emit the same change more than once.
+func TestScanNowConcurrentWithWatch(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewWatcher(WatcherConfig{
+		PollInterval: 10000,
+		WatchItems:   []string{dir},
+		Extensions:   []string{"txt"},
+		Backend:      BackendPoll,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher: %v", err)
+	}
+
+	if err := w.(*watcher).ScanNow(); err != ErrWatcherNotRunning {
+		t.Fatalf("expected ErrWatcherNotRunning before Watch, got %v", err)
+	}
+
+	var mu sync.Mutex
+	seen := map[string]int{}
+
+	go func() {
+		for path := range w.Events() {
+			mu.Lock()
+			seen[path]++
+			mu.Unlock()
+		}
+	}()
+
+	go func() {
+		for err := range w.Errors() {
+			t.Errorf("unexpected watcher error: %v", err)
+		}
+	}()
+
+	target := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(target, []byte("hello"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	go w.Watch()
+	time.Sleep(20 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			defer wg.Done()
+			_ = w.(*watcher).ScanNow()
+		}()
+	}
+	wg.Wait()
+
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if n := seen[target]; n != 1 {
+		t.Fatalf("expected exactly 1 emission for %s, got %d", target, n)
+	}
+}
+
+// TestFSNotifyWatchesNewSubdirectories asserts that a directory created
+// under a watched tree after Watch starts gets its own fsnotify watch
+// registered, so changes inside it are detected rather than silently
+// missed.
+func TestFSNotifyWatchesNewSubdirectories(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewWatcher(WatcherConfig{
+		WatchItems: []string{dir},
+		Extensions: []string{"txt"},
+		Backend:    BackendFSNotify,
+	})
+	if err != nil {
+		t.Skipf("fsnotify unavailable in this environment: %v", err)
+	}
+
+	events := make(chan string, 1)
+	go func() {
+		for path := range w.Events() {
+			select {
+			case events <- path:
+			default:
+			}
+		}
+	}()
+	go func() {
+		for range w.Errors() {
+		}
+	}()
+
+	go w.Watch()
+	time.Sleep(20 * time.Millisecond)
+
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond) // let the Create handler register sub's watch
+
+	target := filepath.Join(sub, "new.txt")
+	if err := os.WriteFile(target, []byte("hello"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	select {
+	case got := <-events:
+		if got != target {
+			t.Fatalf("expected event for %s, got %s", target, got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for an event from the newly created subdirectory")
+	}
+}
+
+// TestFSNotifyWatchesSingleFileWatchItem asserts that a watch item
+// resolving to an individual file, rather than a directory, still gets
+// watched via its parent directory, and that unrelated sibling files in
+// that same directory are filtered out.
+func TestFSNotifyWatchesSingleFileWatchItem(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "only.txt")
+	if err := os.WriteFile(file, []byte("1"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	w, err := NewWatcher(WatcherConfig{
+		WatchItems: []string{file},
+		Extensions: []string{"txt"},
+		Backend:    BackendFSNotify,
+	})
+	if err != nil {
+		t.Skipf("fsnotify unavailable in this environment: %v", err)
+	}
+
+	events := make(chan string, 1)
+	go func() {
+		for path := range w.Events() {
+			select {
+			case events <- path:
+			default:
+			}
+		}
+	}()
+	go func() {
+		for range w.Errors() {
+		}
+	}()
+
+	go w.Watch()
+	time.Sleep(20 * time.Millisecond)
+
+	sibling := filepath.Join(dir, "sibling.txt")
+	if err := os.WriteFile(sibling, []byte("ignore me"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	select {
+	case got := <-events:
+		t.Fatalf("expected sibling file to be filtered out, got event for %s", got)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(file, []byte("2"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	select {
+	case got := <-events:
+		if got != file {
+			t.Fatalf("expected event for %s, got %s", file, got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for an event on the single watched file")
+	}
+}
+
+// TestIsIgnorePattern asserts the literal-path/gitignore-pattern split
+// used by splitIgnoreEntries.
+func TestIsIgnorePattern(t *testing.T) {
+	cases := []struct {
+		item string
+		want bool
+	}{
+		{"vendor/", true},
+		{"**/node_modules", true},
+		{"!important.go", true},
+		{"*.log", true},
+		{"src/main.go", false},
+		{"node_modules", false},
+	}
+
+	for _, c := range cases {
+		if got := isIgnorePattern(c.item); got != c.want {
+			t.Errorf("isIgnorePattern(%q) = %v, want %v", c.item, got, c.want)
+		}
+	}
+}
+
+// TestResolveIgnoresPatternMatching asserts that gitignore-style patterns
+// passed as IgnoreItems, including negation, are compiled into a working
+// matcher alongside the literal ignore paths.
+func TestResolveIgnoresPatternMatching(t *testing.T) {
+	literal, matcher, err := resolveIgnores([]string{"*.go", "!important.go", "vendor/", "**/node_modules"})
+	if err != nil {
+		t.Fatalf("resolveIgnores: %v", err)
+	}
+	if len(literal) != 0 {
+		t.Fatalf("expected no literal paths, got %v", literal)
+	}
+	if matcher == nil {
+		t.Fatal("expected a compiled matcher")
+	}
+
+	cases := []struct {
+		path string
+		want bool
+	}{
+		{"main.go", true},
+		{"important.go", false},
+		{"vendor/pkg/file.go", true},
+		{"src/node_modules/foo.js", true},
+		{"README.md", false},
+	}
+
+	for _, c := range cases {
+		if got := matcher.MatchesPath(c.path); got != c.want {
+			t.Errorf("MatchesPath(%q) = %v, want %v", c.path, got, c.want)
+		}
+	}
+}
+
+// TestResolveIgnoresLoadsGaperIgnoreFile asserts that patterns are
+// auto-loaded from a .gaperignore file in the working directory, with
+// blank lines and comments skipped.
+func TestResolveIgnoresLoadsGaperIgnoreFile(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd: %v", err)
+	}
+	defer func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatalf("Chdir back: %v", err)
+		}
+	}()
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Chdir: %v", err)
+	}
+
+	contents := "vendor/\n# a comment\n\n*.log\n"
+	if err := os.WriteFile(gaperIgnoreFile, []byte(contents), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	_, matcher, err := resolveIgnores(nil)
+	if err != nil {
+		t.Fatalf("resolveIgnores: %v", err)
+	}
+	if matcher == nil {
+		t.Fatal("expected a matcher compiled from .gaperignore")
+	}
+
+	if !matcher.MatchesPath("vendor/a.go") {
+		t.Error("expected vendor/a.go to be ignored")
+	}
+	if !matcher.MatchesPath("debug.log") {
+		t.Error("expected debug.log to be ignored")
+	}
+	if matcher.MatchesPath("main.go") {
+		t.Error("expected main.go not to be ignored")
+	}
+}
+
+// TestToEventClassifiesOps asserts that toEvent maps each fsnotify op bit
+// to the matching gaper Op, falling back to Write when none of the other
+// bits are set.
+func TestToEventClassifiesOps(t *testing.T) {
+	w := &watcher{}
+
+	cases := []struct {
+		op   fsnotify.Op
+		want Op
+	}{
+		{fsnotify.Create, Create},
+		{fsnotify.Remove, Remove},
+		{fsnotify.Rename, Rename},
+		{fsnotify.Chmod, Chmod},
+		{fsnotify.Write, Write},
+	}
+
+	for _, c := range cases {
+		ev := w.toEvent(fsnotify.Event{Name: "nonexistent", Op: c.op})
+		if ev.Op != c.want {
+			t.Errorf("toEvent(Op=%v).Op = %v, want %v", c.op, ev.Op, c.want)
+		}
+	}
+}
+
+// TestScanChangeClassifiesCreateThenWrite asserts that scanChange reports
+// Create the first time a path is seen and Write on subsequent changes to
+// the same path.
+func TestScanChangeClassifiesCreateThenWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewWatcher(WatcherConfig{
+		WatchItems: []string{dir},
+		Extensions: []string{"txt"},
+		Backend:    BackendPoll,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher: %v", err)
+	}
+	wc := w.(*watcher)
+
+	file := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(file, []byte("1"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	ev, err := wc.scanChange(dir)
+	if err != nil {
+		t.Fatalf("scanChange: %v", err)
+	}
+	if ev.Path != file || ev.Op != Create {
+		t.Fatalf("expected Create for %s, got %+v", file, ev)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := os.WriteFile(file, []byte("22"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	ev, err = wc.scanChange(dir)
+	if err != nil {
+		t.Fatalf("scanChange: %v", err)
+	}
+	if ev.Path != file || ev.Op != Write {
+		t.Fatalf("expected Write for %s, got %+v", file, ev)
+	}
+}